@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrDecrypt is returned when message content cannot be decrypted with the
+// configured key, almost always because the wrong key (or passphrase) was
+// supplied. Callers such as the TUI should catch it and prompt for the key
+// again rather than treating it as a generic storage failure.
+var ErrDecrypt = errors.New("storage: failed to decrypt content (wrong key?)")
+
+const (
+	encryptionKeySize  = 32 // AES-256
+	encryptionSaltSize = 16
+	scryptN            = 1 << 15
+	scryptR            = 8
+	scryptP            = 1
+)
+
+// deriveKey resolves a user-supplied Options.EncryptionKey into a 32-byte AES
+// key. A key that is already 32 bytes is used verbatim; anything else is
+// treated as a passphrase and stretched with scrypt using salt.
+func deriveKey(raw, salt []byte) ([]byte, error) {
+	if len(raw) == encryptionKeySize {
+		return raw, nil
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("storage: empty encryption key")
+	}
+
+	key, err := scrypt.Key(raw, salt, scryptN, scryptR, scryptP, encryptionKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive encryption key: %w", err)
+	}
+	return key, nil
+}
+
+// newEncryptionSalt generates a random salt for passphrase-based key derivation.
+func newEncryptionSalt() ([]byte, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate encryption salt: %w", err)
+	}
+	return salt, nil
+}
+
+// encryptContent seals plaintext with key under a fresh random nonce,
+// returning the base64-encoded ciphertext (safe to store in a TEXT column)
+// and the raw nonce (stored separately, e.g. in a BLOB column).
+func encryptContent(key []byte, plaintext string) (ciphertext string, nonce []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nonce, nil
+}
+
+// decryptContent reverses encryptContent. It returns ErrDecrypt (wrapping the
+// underlying cause) whenever authentication fails, since that almost always
+// means the wrong key was supplied rather than corrupt storage.
+func decryptContent(key []byte, ciphertext string, nonce []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("init cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("init gcm: %w", err)
+	}
+	return gcm, nil
+}
+
+// DecryptExport decrypts a single piece of ciphertext produced by a Store's
+// envelope encryption, given the raw column values and the same key (or
+// passphrase-derived key) the store was opened with. It lets recovery
+// tooling decrypt a raw database dump without going through a live Store.
+func DecryptExport(key []byte, salt, nonce []byte, ciphertext string) (string, error) {
+	resolved, err := deriveKey(key, salt)
+	if err != nil {
+		return "", err
+	}
+	return decryptContent(resolved, ciphertext, nonce)
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ExportFormat selects the wire format used by ExportSession and ImportSession.
+type ExportFormat int
+
+const (
+	// FormatJSON is a stable, machine-readable schema suitable for backups
+	// and migration between chatty instances.
+	FormatJSON ExportFormat = iota
+	// FormatMarkdown is a human-readable transcript with role headings and
+	// fenced code blocks preserved as written.
+	FormatMarkdown
+)
+
+// exportSchemaVersion is bumped whenever exportedSession's JSON shape changes
+// in a way that readers need to account for.
+const exportSchemaVersion = 1
+
+// exportedSession is the stable JSON representation of a session produced by
+// ExportSession(FormatJSON) and consumed by ImportSession(FormatJSON).
+type exportedSession struct {
+	SchemaVersion int               `json:"schema_version"`
+	Name          string            `json:"name"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	Messages      []exportedMessage `json:"messages"`
+}
+
+type exportedMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func transcriptToExported(tr *Transcript) exportedSession {
+	exported := exportedSession{
+		SchemaVersion: exportSchemaVersion,
+		Name:          tr.Summary.Name,
+		CreatedAt:     tr.Summary.CreatedAt,
+		UpdatedAt:     tr.Summary.UpdatedAt,
+		Messages:      make([]exportedMessage, len(tr.Messages)),
+	}
+	for i, m := range tr.Messages {
+		exported.Messages[i] = exportedMessage{Role: m.Role, Content: m.Content, CreatedAt: m.CreatedAt}
+	}
+	return exported
+}
+
+func encodeSessionJSON(tr *Transcript, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(transcriptToExported(tr)); err != nil {
+		return fmt.Errorf("encode json export: %w", err)
+	}
+	return nil
+}
+
+func encodeSessionMarkdown(tr *Transcript, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "# %s\n\n", tr.Summary.Name); err != nil {
+		return fmt.Errorf("write markdown title: %w", err)
+	}
+	for _, m := range tr.Messages {
+		if _, err := fmt.Fprintf(bw, "## %s — %s\n\n%s\n\n", m.Role, m.CreatedAt.UTC().Format(timestampLayout), m.Content); err != nil {
+			return fmt.Errorf("write markdown message: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("flush markdown export: %w", err)
+	}
+	return nil
+}
+
+var markdownHeadingPattern = regexp.MustCompile(`^## (\S+) — (\S+)$`)
+
+// decodeSessionExport reads a transcript in the given format, returning the
+// session name and its messages in order.
+func decodeSessionExport(format ExportFormat, r io.Reader) (string, []exportedMessage, error) {
+	switch format {
+	case FormatJSON:
+		var exported exportedSession
+		if err := json.NewDecoder(r).Decode(&exported); err != nil {
+			return "", nil, fmt.Errorf("decode json import: %w", err)
+		}
+		return exported.Name, exported.Messages, nil
+	case FormatMarkdown:
+		return decodeSessionMarkdown(r)
+	default:
+		return "", nil, fmt.Errorf("unsupported export format: %d", format)
+	}
+}
+
+func decodeSessionMarkdown(r io.Reader) (string, []exportedMessage, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var name string
+	var messages []exportedMessage
+	var role string
+	var createdAt time.Time
+	var content strings.Builder
+	haveMessage := false
+
+	flush := func() error {
+		if !haveMessage {
+			return nil
+		}
+		// Each message is rendered as "<blank>\n<content>\n<blank>": strip the
+		// separator blank lines introduced by encodeSessionMarkdown, not the
+		// message's own content.
+		body := strings.TrimPrefix(content.String(), "\n")
+		body = strings.TrimSuffix(body, "\n\n")
+		messages = append(messages, exportedMessage{
+			Role:      role,
+			Content:   body,
+			CreatedAt: createdAt,
+		})
+		content.Reset()
+		haveMessage = false
+		return nil
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# "):
+			name = strings.TrimPrefix(line, "# ")
+		case markdownHeadingPattern.MatchString(line):
+			if err := flush(); err != nil {
+				return "", nil, err
+			}
+			matches := markdownHeadingPattern.FindStringSubmatch(line)
+			role = matches[1]
+			ts, err := time.Parse(timestampLayout, matches[2])
+			if err != nil {
+				return "", nil, fmt.Errorf("parse markdown timestamp %q: %w", matches[2], err)
+			}
+			createdAt = ts
+			haveMessage = true
+		default:
+			if haveMessage {
+				content.WriteString(line)
+				content.WriteString("\n")
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, fmt.Errorf("scan markdown import: %w", err)
+	}
+	if err := flush(); err != nil {
+		return "", nil, err
+	}
+
+	return name, messages, nil
+}
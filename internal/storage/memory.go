@@ -0,0 +1,735 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory Store backend with no persistence, intended
+// for tests and short-lived sessions.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	nextSessionID int64
+	nextMessageID int64
+	sessions      map[int64]*memSession
+	messages      map[int64]*memMessage
+	defaultTTL    time.Duration
+}
+
+type memSession struct {
+	id           int64
+	name         string
+	createdAt    time.Time
+	updatedAt    time.Time
+	activeLeafID int64 // 0 means unset
+	expiresAt    *time.Time
+}
+
+type memMessage struct {
+	id        int64
+	sessionID int64
+	parentID  int64 // 0 means no parent
+	role      string
+	content   string
+	createdAt time.Time
+}
+
+// OpenMemory creates an empty in-memory store. Data does not survive Close.
+// Options.EncryptionKey is ignored: content only ever lives in process
+// memory, so there is no at-rest footprint for it to protect.
+func OpenMemory(opts ...Options) *MemoryStore {
+	opt := mergeOptions(opts)
+	return &MemoryStore{
+		sessions:   make(map[int64]*memSession),
+		messages:   make(map[int64]*memMessage),
+		defaultTTL: opt.DefaultTTL,
+	}
+}
+
+// Close is a no-op for MemoryStore; it exists to satisfy Store.
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// CreateSession inserts a new conversation and returns its identifier.
+func (s *MemoryStore) CreateSession(ctx context.Context, name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	title := strings.TrimSpace(name)
+	if title == "" {
+		title = fmt.Sprintf("Session %s", time.Now().Format("2006-01-02 15:04"))
+	}
+
+	s.nextSessionID++
+	id := s.nextSessionID
+	now := time.Now().UTC()
+	sess := &memSession{id: id, name: title, createdAt: now, updatedAt: now}
+	if s.defaultTTL > 0 {
+		expiresAt := now.Add(s.defaultTTL)
+		sess.expiresAt = &expiresAt
+	}
+	s.sessions[id] = sess
+	return id, nil
+}
+
+// UpdateSessionName updates the stored name for a session.
+func (s *MemoryStore) UpdateSessionName(ctx context.Context, id int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trimmed := strings.TrimSpace(name)
+	if trimmed == "" {
+		return errors.New("session name cannot be empty")
+	}
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return fmt.Errorf("session %d not found", id)
+	}
+	sess.name = trimmed
+	sess.updatedAt = time.Now().UTC()
+	return nil
+}
+
+// AppendMessage appends a message to the specified session, continuing its
+// currently selected branch.
+func (s *MemoryStore) AppendMessage(ctx context.Context, sessionID int64, message Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if strings.TrimSpace(message.Role) == "" {
+		return errors.New("message role cannot be empty")
+	}
+
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session %d not found", sessionID)
+	}
+
+	s.nextMessageID++
+	id := s.nextMessageID
+	now := time.Now().UTC()
+	s.messages[id] = &memMessage{
+		id:        id,
+		sessionID: sessionID,
+		parentID:  sess.activeLeafID,
+		role:      message.Role,
+		content:   message.Content,
+		createdAt: now,
+	}
+	sess.activeLeafID = id
+	sess.updatedAt = now
+	return nil
+}
+
+// ListSessions returns stored conversations ordered by most recent activity.
+func (s *MemoryStore) ListSessions(ctx context.Context, limit int) ([]SessionSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summaries := make([]SessionSummary, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		summaries = append(summaries, SessionSummary{
+			ID:           sess.id,
+			Name:         sess.name,
+			CreatedAt:    sess.createdAt,
+			UpdatedAt:    sess.updatedAt,
+			MessageCount: s.countMessages(sess.id),
+			ExpiresAt:    sess.expiresAt,
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt) })
+
+	if limit > 0 && len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+	return summaries, nil
+}
+
+func (s *MemoryStore) countMessages(sessionID int64) int {
+	count := 0
+	for _, m := range s.messages {
+		if m.sessionID == sessionID {
+			count++
+		}
+	}
+	return count
+}
+
+// LoadSession fetches the session metadata and the transcript of its
+// currently selected branch.
+func (s *MemoryStore) LoadSession(ctx context.Context, id int64) (*Transcript, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("session %d not found", id)
+	}
+
+	summary := SessionSummary{
+		ID:           sess.id,
+		Name:         sess.name,
+		CreatedAt:    sess.createdAt,
+		UpdatedAt:    sess.updatedAt,
+		MessageCount: s.countMessages(sess.id),
+		ExpiresAt:    sess.expiresAt,
+	}
+
+	var messages []Message
+	if sess.activeLeafID != 0 {
+		path, err := s.loadPathLocked(sess.activeLeafID)
+		if err != nil {
+			return nil, err
+		}
+		messages = path
+	}
+
+	return &Transcript{Summary: summary, Messages: messages}, nil
+}
+
+// activePathSetLocked returns the set of message ids reachable from some
+// session's active_leaf_id, i.e. the messages LoadSession would return for
+// at least one session. Callers must already hold s.mu.
+func (s *MemoryStore) activePathSetLocked() map[int64]bool {
+	active := make(map[int64]bool)
+	for _, sess := range s.sessions {
+		id := sess.activeLeafID
+		for id != 0 && !active[id] {
+			active[id] = true
+			m, ok := s.messages[id]
+			if !ok {
+				break
+			}
+			id = m.parentID
+		}
+	}
+	return active
+}
+
+// SearchMessages searches stored message content with a case-insensitive
+// substring match; MemoryStore has no native full-text index. Results are
+// restricted to each message's session's active branch, matching LoadSession.
+func (s *MemoryStore) SearchMessages(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if strings.TrimSpace(query.Match) == "" {
+		return nil, errors.New("search match cannot be empty")
+	}
+
+	active := s.activePathSetLocked()
+	candidates := make([]*memMessage, 0, len(s.messages))
+	for _, m := range s.messages {
+		candidates = append(candidates, m)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].id > candidates[j].id })
+
+	match := strings.ToLower(query.Match)
+	hits := make([]SearchHit, 0, 16)
+	for _, m := range candidates {
+		if !active[m.id] {
+			continue
+		}
+		if query.SessionID > 0 && m.sessionID != query.SessionID {
+			continue
+		}
+		if role := strings.TrimSpace(query.Role); role != "" && m.role != role {
+			continue
+		}
+		if !query.Since.IsZero() && m.createdAt.Before(query.Since) {
+			continue
+		}
+		if !query.Until.IsZero() && m.createdAt.After(query.Until) {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(m.content), match) {
+			continue
+		}
+
+		hits = append(hits, SearchHit{
+			SessionID: m.sessionID,
+			MessageID: m.id,
+			Role:      m.role,
+			CreatedAt: m.createdAt,
+			Snippet:   snippetAround(m.content, query.Match, 40),
+		})
+		if query.Limit > 0 && len(hits) >= query.Limit {
+			break
+		}
+	}
+
+	return hits, nil
+}
+
+// MessagesBefore returns messages from sessionID's active branch created
+// strictly before cursor, in chronological order.
+func (s *MemoryStore) MessagesBefore(ctx context.Context, sessionID int64, cursor time.Time, limit int) ([]Message, error) {
+	return s.pageMessages(sessionID, cursor, limit, func(t time.Time) bool { return t.Before(cursor) }, true)
+}
+
+// MessagesAfter returns messages from sessionID's active branch created
+// strictly after cursor, in chronological order.
+func (s *MemoryStore) MessagesAfter(ctx context.Context, sessionID int64, cursor time.Time, limit int) ([]Message, error) {
+	return s.pageMessages(sessionID, cursor, limit, func(t time.Time) bool { return t.After(cursor) }, false)
+}
+
+// pageMessages restricts to sessionID's active branch (the same path
+// LoadSession walks), so an abandoned branch created by BranchFromMessage
+// never interleaves with the live conversation in a paginated view.
+func (s *MemoryStore) pageMessages(sessionID int64, cursor time.Time, limit int, keep func(time.Time) bool, newestFirst bool) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sessionID <= 0 {
+		return nil, errors.New("invalid session id")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	active := s.activePathSetLocked()
+	var candidates []*memMessage
+	for _, m := range s.messages {
+		if m.sessionID == sessionID && active[m.id] && keep(m.createdAt) {
+			candidates = append(candidates, m)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if newestFirst {
+			return candidates[i].id > candidates[j].id
+		}
+		return candidates[i].id < candidates[j].id
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	if newestFirst {
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+
+	messages := make([]Message, len(candidates))
+	for i, m := range candidates {
+		messages[i] = toMessage(m)
+	}
+	return messages, nil
+}
+
+// BranchFromMessage forks the conversation at msgID into a new sibling
+// holding newContent, and returns the new message's id.
+func (s *MemoryStore) BranchFromMessage(ctx context.Context, msgID int64, newContent string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[msgID]
+	if !ok {
+		return 0, fmt.Errorf("message %d not found", msgID)
+	}
+	sess, ok := s.sessions[msg.sessionID]
+	if !ok {
+		return 0, fmt.Errorf("session %d not found", msg.sessionID)
+	}
+
+	s.nextMessageID++
+	id := s.nextMessageID
+	s.messages[id] = &memMessage{
+		id:        id,
+		sessionID: msg.sessionID,
+		parentID:  msg.parentID,
+		role:      msg.role,
+		content:   newContent,
+		createdAt: time.Now().UTC(),
+	}
+	sess.activeLeafID = id
+	sess.updatedAt = time.Now().UTC()
+	return id, nil
+}
+
+// SwitchBranch makes messageID the active leaf of sessionID.
+func (s *MemoryStore) SwitchBranch(ctx context.Context, sessionID, messageID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session %d not found", sessionID)
+	}
+	msg, ok := s.messages[messageID]
+	if !ok {
+		return fmt.Errorf("message %d not found", messageID)
+	}
+	if msg.sessionID != sessionID {
+		return fmt.Errorf("message %d does not belong to session %d", messageID, sessionID)
+	}
+
+	sess.activeLeafID = messageID
+	return nil
+}
+
+// ListChildren returns the direct children of messageID ordered by creation.
+func (s *MemoryStore) ListChildren(ctx context.Context, messageID int64) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var children []*memMessage
+	for _, m := range s.messages {
+		if m.parentID == messageID {
+			children = append(children, m)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].id < children[j].id })
+
+	messages := make([]Message, len(children))
+	for i, m := range children {
+		messages[i] = toMessage(m)
+	}
+	return messages, nil
+}
+
+// LoadPath returns the messages from leafID's trunk root to leafID.
+func (s *MemoryStore) LoadPath(ctx context.Context, leafID int64) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadPathLocked(leafID)
+}
+
+func (s *MemoryStore) loadPathLocked(leafID int64) ([]Message, error) {
+	var path []Message
+	currentID := leafID
+	for currentID != 0 {
+		m, ok := s.messages[currentID]
+		if !ok {
+			return nil, fmt.Errorf("message %d not found", currentID)
+		}
+		path = append(path, toMessage(m))
+		currentID = m.parentID
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+func toMessage(m *memMessage) Message {
+	msg := Message{ID: m.id, Role: m.role, Content: m.content, CreatedAt: m.createdAt}
+	if m.parentID != 0 {
+		parent := m.parentID
+		msg.ParentID = &parent
+	}
+	return msg
+}
+
+// ExportSession writes the session's active transcript to w in the given format.
+func (s *MemoryStore) ExportSession(ctx context.Context, id int64, format ExportFormat, w io.Writer) error {
+	tr, err := s.LoadSession(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		return encodeSessionJSON(tr, w)
+	case FormatMarkdown:
+		return encodeSessionMarkdown(tr, w)
+	default:
+		return fmt.Errorf("unsupported export format: %d", format)
+	}
+}
+
+// ImportSession reads a transcript in the given format from r, creates a new
+// session for it, and returns the new session id.
+func (s *MemoryStore) ImportSession(ctx context.Context, format ExportFormat, r io.Reader) (int64, error) {
+	name, messages, err := decodeSessionExport(format, r)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	title := strings.TrimSpace(name)
+	if title == "" {
+		title = "Imported session"
+	}
+
+	s.nextSessionID++
+	sessionID := s.nextSessionID
+	now := time.Now().UTC()
+	sess := &memSession{id: sessionID, name: title, createdAt: now, updatedAt: now}
+	s.sessions[sessionID] = sess
+
+	var parentID int64
+	for _, m := range messages {
+		s.nextMessageID++
+		id := s.nextMessageID
+		s.messages[id] = &memMessage{
+			id:        id,
+			sessionID: sessionID,
+			parentID:  parentID,
+			role:      m.Role,
+			content:   m.Content,
+			createdAt: m.CreatedAt,
+		}
+		parentID = id
+		sess.activeLeafID = id
+	}
+
+	return sessionID, nil
+}
+
+// ExportAll streams every session as one NDJSON line per session.
+func (s *MemoryStore) ExportAll(ctx context.Context, w io.Writer) error {
+	s.mu.Lock()
+	ids := make([]int64, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if err := s.ExportSession(ctx, id, FormatJSON, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloneSession duplicates a session and every message in its tree (all
+// branches, not just the active path) under newName.
+func (s *MemoryStore) CloneSession(ctx context.Context, id int64, newName string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return 0, fmt.Errorf("session %d not found", id)
+	}
+
+	title := strings.TrimSpace(newName)
+	if title == "" {
+		title = sess.name + " (copy)"
+	}
+
+	s.nextSessionID++
+	newID := s.nextSessionID
+	now := time.Now().UTC()
+	newSess := &memSession{id: newID, name: title, createdAt: now, updatedAt: now}
+	s.sessions[newID] = newSess
+
+	var ordered []*memMessage
+	for _, m := range s.messages {
+		if m.sessionID == id {
+			ordered = append(ordered, m)
+		}
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].id < ordered[j].id })
+
+	idMap := make(map[int64]int64, len(ordered))
+	for _, m := range ordered {
+		s.nextMessageID++
+		newMsgID := s.nextMessageID
+		var newParent int64
+		if m.parentID != 0 {
+			mapped, ok := idMap[m.parentID]
+			if !ok {
+				return 0, fmt.Errorf("clone session: parent %d seen before message %d", m.parentID, m.id)
+			}
+			newParent = mapped
+		}
+		s.messages[newMsgID] = &memMessage{
+			id:        newMsgID,
+			sessionID: newID,
+			parentID:  newParent,
+			role:      m.role,
+			content:   m.content,
+			createdAt: m.createdAt,
+		}
+		idMap[m.id] = newMsgID
+		if m.id == sess.activeLeafID {
+			newSess.activeLeafID = newMsgID
+		}
+	}
+
+	return newID, nil
+}
+
+// ExportSessionTree returns a full-fidelity snapshot of id's entire message
+// tree (every branch, not just the active path), for cross-backend migration.
+func (s *MemoryStore) ExportSessionTree(ctx context.Context, id int64) (*SessionTree, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("session %d not found", id)
+	}
+
+	var ordered []*memMessage
+	for _, m := range s.messages {
+		if m.sessionID == id {
+			ordered = append(ordered, m)
+		}
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].id < ordered[j].id })
+
+	tree := &SessionTree{Name: sess.name, CreatedAt: sess.createdAt, UpdatedAt: sess.updatedAt}
+	seqByID := make(map[int64]int, len(ordered))
+	for _, m := range ordered {
+		seq := len(tree.Messages) + 1
+		var parentSeq int
+		if m.parentID != 0 {
+			parentSeq = seqByID[m.parentID]
+		}
+		tree.Messages = append(tree.Messages, SessionTreeMessage{
+			Seq: seq, ParentSeq: parentSeq, Role: m.role, Content: m.content, CreatedAt: m.createdAt,
+		})
+		seqByID[m.id] = seq
+		if m.id == sess.activeLeafID {
+			tree.ActiveLeafSeq = seq
+		}
+	}
+
+	return tree, nil
+}
+
+// ImportSessionTree recreates a session from a snapshot produced by
+// ExportSessionTree, preserving its branch structure.
+func (s *MemoryStore) ImportSessionTree(ctx context.Context, tree *SessionTree) (int64, error) {
+	if tree == nil {
+		return 0, errors.New("session tree cannot be nil")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	title := strings.TrimSpace(tree.Name)
+	if title == "" {
+		title = "Imported session"
+	}
+
+	s.nextSessionID++
+	sessionID := s.nextSessionID
+	now := time.Now().UTC()
+	sess := &memSession{id: sessionID, name: title, createdAt: now, updatedAt: now}
+	s.sessions[sessionID] = sess
+
+	idBySeq := make(map[int]int64, len(tree.Messages))
+	for _, m := range tree.Messages {
+		var parentID int64
+		if m.ParentSeq > 0 {
+			parent, ok := idBySeq[m.ParentSeq]
+			if !ok {
+				return 0, fmt.Errorf("import session tree: parent seq %d seen before message seq %d", m.ParentSeq, m.Seq)
+			}
+			parentID = parent
+		}
+
+		s.nextMessageID++
+		newID := s.nextMessageID
+		s.messages[newID] = &memMessage{
+			id:        newID,
+			sessionID: sessionID,
+			parentID:  parentID,
+			role:      m.Role,
+			content:   m.Content,
+			createdAt: m.CreatedAt,
+		}
+		idBySeq[m.Seq] = newID
+		if m.Seq == tree.ActiveLeafSeq {
+			sess.activeLeafID = newID
+		}
+	}
+
+	return sessionID, nil
+}
+
+// PruneOlderThan deletes every session whose expiry is at or before cutoff,
+// along with its messages, and returns how many of each were removed.
+func (s *MemoryStore) PruneOlderThan(ctx context.Context, cutoff time.Time) (deletedSessions, deletedMessages int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sess := range s.sessions {
+		if sess.expiresAt == nil || sess.expiresAt.After(cutoff) {
+			continue
+		}
+		for msgID, m := range s.messages {
+			if m.sessionID == id {
+				delete(s.messages, msgID)
+				deletedMessages++
+			}
+		}
+		delete(s.sessions, id)
+		deletedSessions++
+	}
+
+	return deletedSessions, deletedMessages, nil
+}
+
+// PruneBySessionCount deletes the oldest sessions (by updatedAt) beyond
+// keepMostRecent, along with their messages, and returns how many sessions
+// were removed.
+func (s *MemoryStore) PruneBySessionCount(ctx context.Context, keepMostRecent int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if keepMostRecent < 0 {
+		return 0, errors.New("keepMostRecent cannot be negative")
+	}
+	if len(s.sessions) <= keepMostRecent {
+		return 0, nil
+	}
+
+	ordered := make([]*memSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		ordered = append(ordered, sess)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].updatedAt.After(ordered[j].updatedAt) })
+
+	var deleted int
+	for _, sess := range ordered[keepMostRecent:] {
+		for msgID, m := range s.messages {
+			if m.sessionID == sess.id {
+				delete(s.messages, msgID)
+			}
+		}
+		delete(s.sessions, sess.id)
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// SetSessionTTL sets sessionID's expiry to ttl from now, or clears it when
+// ttl is zero or negative.
+func (s *MemoryStore) SetSessionTTL(ctx context.Context, sessionID int64, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session %d not found", sessionID)
+	}
+
+	if ttl <= 0 {
+		sess.expiresAt = nil
+		return nil
+	}
+	expiresAt := time.Now().UTC().Add(ttl)
+	sess.expiresAt = &expiresAt
+	return nil
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// BranchFromMessage forks the conversation at msgID into a new sibling
+// holding newContent, and returns the new message's id.
+func (s *PostgresStore) BranchFromMessage(ctx context.Context, msgID int64, newContent string) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("storage not initialised")
+	}
+	if msgID <= 0 {
+		return 0, errors.New("invalid message id")
+	}
+
+	var sessionID int64
+	var parentID sql.NullInt64
+	var role string
+	row := s.db.QueryRowContext(ctx, `SELECT session_id, parent_id, role FROM messages WHERE id = $1`, msgID)
+	if err := row.Scan(&sessionID, &parentID, &role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("message %d not found", msgID)
+		}
+		return 0, fmt.Errorf("select message: %w", err)
+	}
+
+	content, nonce, encrypted, err := s.encryptRow(newContent)
+	if err != nil {
+		return 0, err
+	}
+
+	var newID int64
+	if err := s.db.QueryRowContext(ctx, `INSERT INTO messages(session_id, parent_id, role, content, nonce, content_encrypted) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		sessionID, nullableInt64(parentID), role, content, nonce, encrypted).Scan(&newID); err != nil {
+		return 0, fmt.Errorf("insert branch: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET updated_at = (now() at time zone 'utc'), active_leaf_id = $1 WHERE id = $2`, newID, sessionID); err != nil {
+		return 0, fmt.Errorf("select branch: %w", err)
+	}
+
+	return newID, nil
+}
+
+// SwitchBranch makes messageID the active leaf of sessionID.
+func (s *PostgresStore) SwitchBranch(ctx context.Context, sessionID, messageID int64) error {
+	if s == nil || s.db == nil {
+		return errors.New("storage not initialised")
+	}
+	if sessionID <= 0 {
+		return errors.New("invalid session id")
+	}
+	if messageID <= 0 {
+		return errors.New("invalid message id")
+	}
+
+	var owner int64
+	if err := s.db.QueryRowContext(ctx, `SELECT session_id FROM messages WHERE id = $1`, messageID).Scan(&owner); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("message %d not found", messageID)
+		}
+		return fmt.Errorf("select message: %w", err)
+	}
+	if owner != sessionID {
+		return fmt.Errorf("message %d does not belong to session %d", messageID, sessionID)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET active_leaf_id = $1 WHERE id = $2`, messageID, sessionID); err != nil {
+		return fmt.Errorf("switch branch: %w", err)
+	}
+
+	return nil
+}
+
+// ListChildren returns the direct children of messageID ordered by creation.
+func (s *PostgresStore) ListChildren(ctx context.Context, messageID int64) ([]Message, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, parent_id, role, content, created_at, content_encrypted, nonce FROM messages WHERE parent_id = $1 ORDER BY id ASC`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("list children: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanPostgresMessages(rows)
+}
+
+// LoadPath returns the messages from leafID's trunk root to leafID.
+func (s *PostgresStore) LoadPath(ctx context.Context, leafID int64) ([]Message, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+	if leafID <= 0 {
+		return nil, errors.New("invalid message id")
+	}
+
+	var path []Message
+	currentID := sql.NullInt64{Int64: leafID, Valid: true}
+	for currentID.Valid {
+		var msg Message
+		var parentID sql.NullInt64
+		var encryptedFlag bool
+		var nonce []byte
+		row := s.db.QueryRowContext(ctx, `SELECT id, parent_id, role, content, created_at, content_encrypted, nonce FROM messages WHERE id = $1`, currentID.Int64)
+		if err := row.Scan(&msg.ID, &parentID, &msg.Role, &msg.Content, &msg.CreatedAt, &encryptedFlag, &nonce); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("message %d not found", currentID.Int64)
+			}
+			return nil, fmt.Errorf("select message: %w", err)
+		}
+		var err error
+		msg.Content, err = s.decryptRow(msg.Content, nonce, encryptedFlag)
+		if err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := parentID.Int64
+			msg.ParentID = &id
+		}
+
+		path = append(path, msg)
+		currentID = parentID
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path, nil
+}
+
+func (s *PostgresStore) scanPostgresMessages(rows *sql.Rows) ([]Message, error) {
+	messages := make([]Message, 0, 8)
+	for rows.Next() {
+		var msg Message
+		var parentID sql.NullInt64
+		var encryptedFlag bool
+		var nonce []byte
+		if err := rows.Scan(&msg.ID, &parentID, &msg.Role, &msg.Content, &msg.CreatedAt, &encryptedFlag, &nonce); err != nil {
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		content, err := s.decryptRow(msg.Content, nonce, encryptedFlag)
+		if err != nil {
+			return nil, err
+		}
+		msg.Content = content
+		if parentID.Valid {
+			id := parentID.Int64
+			msg.ParentID = &id
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate messages: %w", err)
+	}
+	return messages, nil
+}
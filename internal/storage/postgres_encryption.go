@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// loadOrCreateSalt returns the database's passphrase-derivation salt,
+// generating and persisting one on first use. The salt is not secret; it
+// only needs to be stable across opens so the same passphrase always derives
+// the same key.
+func (s *PostgresStore) loadOrCreateSalt() ([]byte, error) {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS encryption_meta (
+        id INTEGER PRIMARY KEY CHECK (id = 1),
+        salt BYTEA NOT NULL
+    );`); err != nil {
+		return nil, fmt.Errorf("create encryption_meta table: %w", err)
+	}
+
+	var salt []byte
+	err := s.db.QueryRow(`SELECT salt FROM encryption_meta WHERE id = 1`).Scan(&salt)
+	if err == nil {
+		return salt, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("load encryption salt: %w", err)
+	}
+
+	salt, err = newEncryptionSalt()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`INSERT INTO encryption_meta(id, salt) VALUES (1, $1)`, salt); err != nil {
+		return nil, fmt.Errorf("store encryption salt: %w", err)
+	}
+	return salt, nil
+}
+
+// decryptRow decrypts a message's stored content when encrypted reports it
+// was sealed with s.encKey, returning content unchanged otherwise.
+func (s *PostgresStore) decryptRow(content string, nonce []byte, encrypted bool) (string, error) {
+	if !encrypted {
+		return content, nil
+	}
+	if len(s.encKey) == 0 {
+		return "", fmt.Errorf("%w: no encryption key configured", ErrDecrypt)
+	}
+	return decryptContent(s.encKey, content, nonce)
+}
+
+// encryptRow seals content with s.encKey when encryption is configured,
+// returning the stored content, nonce, and whether it was encrypted.
+func (s *PostgresStore) encryptRow(content string) (stored string, nonce []byte, encrypted bool, err error) {
+	if len(s.encKey) == 0 {
+		return content, nil, false, nil
+	}
+	stored, nonce, err = encryptContent(s.encKey, content)
+	if err != nil {
+		return "", nil, false, err
+	}
+	return stored, nonce, true, nil
+}
+
+// RekeyDatabase re-encrypts every encrypted message's content under newKey,
+// replacing oldKey. Both keys are resolved the same way as
+// Options.EncryptionKey: 32 bytes used as-is, anything else stretched with
+// scrypt using the database's stored salt. On success, the store switches to
+// newKey for subsequent reads and writes.
+func (s *PostgresStore) RekeyDatabase(ctx context.Context, oldKey, newKey []byte) error {
+	if s == nil || s.db == nil {
+		return errors.New("storage not initialised")
+	}
+
+	salt, err := s.loadOrCreateSalt()
+	if err != nil {
+		return err
+	}
+
+	oldResolved, err := deriveKey(oldKey, salt)
+	if err != nil {
+		return fmt.Errorf("resolve old key: %w", err)
+	}
+	newResolved, err := deriveKey(newKey, salt)
+	if err != nil {
+		return fmt.Errorf("resolve new key: %w", err)
+	}
+
+	type encryptedRow struct {
+		id      int64
+		content string
+		nonce   []byte
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, content, nonce FROM messages WHERE content_encrypted`)
+	if err != nil {
+		return fmt.Errorf("load encrypted messages: %w", err)
+	}
+	var encrypted []encryptedRow
+	for rows.Next() {
+		var r encryptedRow
+		if err := rows.Scan(&r.id, &r.content, &r.nonce); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan encrypted message: %w", err)
+		}
+		encrypted = append(encrypted, r)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate encrypted messages: %w", err)
+	}
+	rows.Close()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin rekey: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range encrypted {
+		plaintext, err := decryptContent(oldResolved, r.content, r.nonce)
+		if err != nil {
+			return fmt.Errorf("message %d: %w", r.id, err)
+		}
+		ciphertext, nonce, err := encryptContent(newResolved, plaintext)
+		if err != nil {
+			return fmt.Errorf("message %d: %w", r.id, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE messages SET content = $1, nonce = $2 WHERE id = $3`, ciphertext, nonce, r.id); err != nil {
+			return fmt.Errorf("rekey message %d: %w", r.id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rekey: %w", err)
+	}
+
+	s.encKey = newResolved
+	return nil
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PruneOlderThan deletes every session whose expires_at is set and at or
+// before cutoff, along with its messages, and returns how many of each were
+// removed.
+func (s *PostgresStore) PruneOlderThan(ctx context.Context, cutoff time.Time) (deletedSessions, deletedMessages int, err error) {
+	if s == nil || s.db == nil {
+		return 0, 0, errors.New("storage not initialised")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin prune: %w", err)
+	}
+	defer tx.Rollback()
+
+	msgRes, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE session_id IN (
+        SELECT id FROM sessions WHERE expires_at IS NOT NULL AND expires_at <= $1
+    )`, cutoff.UTC())
+	if err != nil {
+		return 0, 0, fmt.Errorf("prune messages: %w", err)
+	}
+	msgCount, err := msgRes.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("count pruned messages: %w", err)
+	}
+
+	sessRes, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at IS NOT NULL AND expires_at <= $1`, cutoff.UTC())
+	if err != nil {
+		return 0, 0, fmt.Errorf("prune sessions: %w", err)
+	}
+	sessCount, err := sessRes.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("count pruned sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit prune: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `ANALYZE sessions, messages`); err != nil {
+		return int(sessCount), int(msgCount), fmt.Errorf("analyze after prune: %w", err)
+	}
+
+	return int(sessCount), int(msgCount), nil
+}
+
+// PruneBySessionCount deletes the oldest sessions (by updated_at) beyond
+// keepMostRecent, along with their messages, and returns how many sessions
+// were removed.
+func (s *PostgresStore) PruneBySessionCount(ctx context.Context, keepMostRecent int) (int, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("storage not initialised")
+	}
+	if keepMostRecent < 0 {
+		return 0, errors.New("keepMostRecent cannot be negative")
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id IN (
+        SELECT id FROM sessions ORDER BY updated_at DESC OFFSET $1
+    )`, keepMostRecent)
+	if err != nil {
+		return 0, fmt.Errorf("prune by session count: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("count pruned sessions: %w", err)
+	}
+
+	return int(count), nil
+}
+
+// SetSessionTTL sets sessionID's expiry to ttl from now, or clears it when
+// ttl is zero or negative.
+func (s *PostgresStore) SetSessionTTL(ctx context.Context, sessionID int64, ttl time.Duration) error {
+	if s == nil || s.db == nil {
+		return errors.New("storage not initialised")
+	}
+	if sessionID <= 0 {
+		return errors.New("invalid session id")
+	}
+
+	var expiresAt any
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UTC()
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE sessions SET expires_at = $1 WHERE id = $2`, expiresAt, sessionID)
+	if err != nil {
+		return fmt.Errorf("set session ttl: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("confirm session ttl update: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("session %d not found", sessionID)
+	}
+
+	return nil
+}
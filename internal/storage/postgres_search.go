@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// postgresActivePathCTE is a recursive CTE shared by SearchMessages and the
+// MessagesBefore/After pagination queries. It anchors on every session's
+// active_leaf_id and walks parent_id back to each root, producing the set of
+// message ids that are actually reachable from LoadSession for some session
+// rather than sitting on an abandoned branch. Queries join against it so
+// paging and search never surface a message that LoadSession wouldn't.
+const postgresActivePathCTE = `WITH RECURSIVE active_path(id) AS (
+    SELECT active_leaf_id AS id FROM sessions WHERE active_leaf_id IS NOT NULL
+    UNION ALL
+    SELECT m.parent_id FROM messages m JOIN active_path a ON m.id = a.id WHERE m.parent_id IS NOT NULL
+)
+`
+
+// SearchMessages searches stored message content using Postgres's built-in
+// full-text search (to_tsvector/plainto_tsquery), ranked with ts_rank and
+// excerpted with ts_headline. It matches against the stored column as-is, so
+// when Options.EncryptionKey is set, encrypted messages are ciphertext and
+// will not be found; full-text search over at-rest encrypted content is not
+// supported. Results are restricted to each message's session's active
+// branch, matching LoadSession.
+func (s *PostgresStore) SearchMessages(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+	if strings.TrimSpace(query.Match) == "" {
+		return nil, errors.New("search match cannot be empty")
+	}
+
+	sqlQuery := strings.Builder{}
+	sqlQuery.WriteString(postgresActivePathCTE)
+	sqlQuery.WriteString(`SELECT session_id, id, role, created_at,
+        ts_headline('english', content, plainto_tsquery('english', $1)) AS snippet,
+        ts_rank(to_tsvector('english', content), plainto_tsquery('english', $1)) AS rank
+        FROM messages
+        WHERE to_tsvector('english', content) @@ plainto_tsquery('english', $1)
+          AND id IN (SELECT id FROM active_path)`)
+	args := []any{query.Match}
+
+	if query.SessionID > 0 {
+		args = append(args, query.SessionID)
+		fmt.Fprintf(&sqlQuery, " AND session_id = $%d", len(args))
+	}
+	if role := strings.TrimSpace(query.Role); role != "" {
+		args = append(args, role)
+		fmt.Fprintf(&sqlQuery, " AND role = $%d", len(args))
+	}
+	if !query.Since.IsZero() {
+		args = append(args, query.Since)
+		fmt.Fprintf(&sqlQuery, " AND created_at >= $%d", len(args))
+	}
+	if !query.Until.IsZero() {
+		args = append(args, query.Until)
+		fmt.Fprintf(&sqlQuery, " AND created_at <= $%d", len(args))
+	}
+
+	sqlQuery.WriteString(" ORDER BY rank DESC")
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		fmt.Fprintf(&sqlQuery, " LIMIT $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	defer rows.Close()
+
+	hits := make([]SearchHit, 0, 16)
+	for rows.Next() {
+		var hit SearchHit
+		if err := rows.Scan(&hit.SessionID, &hit.MessageID, &hit.Role, &hit.CreatedAt, &hit.Snippet, &hit.Rank); err != nil {
+			return nil, fmt.Errorf("scan search hit: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate search hits: %w", err)
+	}
+
+	return hits, nil
+}
+
+// MessagesBefore returns messages from sessionID's active branch created
+// strictly before cursor, in chronological order.
+func (s *PostgresStore) MessagesBefore(ctx context.Context, sessionID int64, cursor time.Time, limit int) ([]Message, error) {
+	return s.pageMessages(ctx, sessionID, cursor, limit, "<", "DESC")
+}
+
+// MessagesAfter returns messages from sessionID's active branch created
+// strictly after cursor, in chronological order.
+func (s *PostgresStore) MessagesAfter(ctx context.Context, sessionID int64, cursor time.Time, limit int) ([]Message, error) {
+	return s.pageMessages(ctx, sessionID, cursor, limit, ">", "ASC")
+}
+
+// pageMessages restricts to sessionID's active branch (the same path
+// LoadSession walks), so an abandoned branch created by BranchFromMessage
+// never interleaves with the live conversation in a paginated view.
+func (s *PostgresStore) pageMessages(ctx context.Context, sessionID int64, cursor time.Time, limit int, op, order string) ([]Message, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+	if sessionID <= 0 {
+		return nil, errors.New("invalid session id")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	query := fmt.Sprintf(postgresActivePathCTE+`SELECT id, parent_id, role, content, created_at, content_encrypted, nonce FROM messages
+        WHERE session_id = $1 AND id IN (SELECT id FROM active_path) AND created_at %s $2
+        ORDER BY created_at %s, id %s
+        LIMIT $3`, op, order, order)
+
+	rows, err := s.db.QueryContext(ctx, query, sessionID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("page messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages, err := s.scanPostgresMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if order == "DESC" {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, nil
+}
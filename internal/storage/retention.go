@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Options configures optional behaviour shared across storage backends.
+// The zero value disables all of it: no default TTL, no session cap, and no
+// background retention sweeps.
+type Options struct {
+	// RetentionInterval, if positive, starts a background goroutine from Open
+	// that periodically calls PruneOlderThan and, if MaxSessions is set,
+	// PruneBySessionCount.
+	RetentionInterval time.Duration
+	// DefaultTTL, if positive, is the expiry new sessions are created with
+	// when no explicit SetSessionTTL call overrides it.
+	DefaultTTL time.Duration
+	// MaxSessions, if positive, bounds the number of sessions a backend
+	// keeps; the background sweep prunes the oldest beyond this count.
+	MaxSessions int
+	// EncryptionKey, if set, enables envelope encryption of message content
+	// at rest. A 32-byte key is used as-is (AES-256); any other length is
+	// treated as a passphrase and stretched into a 32-byte key with scrypt.
+	// See ErrDecrypt and RekeyDatabase.
+	EncryptionKey []byte
+}
+
+// mergeOptions collapses the variadic opts a backend constructor receives
+// into a single Options value. Only the first element is used; callers pass
+// at most one in practice, and the variadic form just lets it stay optional.
+func mergeOptions(opts []Options) Options {
+	if len(opts) == 0 {
+		return Options{}
+	}
+	return opts[0]
+}
+
+// retentionStore wraps a Store with a background goroutine that periodically
+// prunes expired sessions (and, if configured, caps the session count),
+// stopping the goroutine when Close is called.
+type retentionStore struct {
+	Store
+	stop chan struct{}
+}
+
+// startRetention wraps base with a background retention sweep per opts, or
+// returns base unchanged if opts.RetentionInterval is not positive.
+func startRetention(base Store, opts Options) Store {
+	if opts.RetentionInterval <= 0 {
+		return base
+	}
+
+	rs := &retentionStore{Store: base, stop: make(chan struct{})}
+	go rs.run(opts)
+	return rs
+}
+
+func (rs *retentionStore) run(opts Options) {
+	ticker := time.NewTicker(opts.RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rs.sweep(opts)
+		case <-rs.stop:
+			return
+		}
+	}
+}
+
+func (rs *retentionStore) sweep(opts Options) {
+	ctx := context.Background()
+
+	if _, _, err := rs.Store.PruneOlderThan(ctx, time.Now()); err != nil {
+		log.Printf("storage: retention sweep: prune expired sessions: %v", err)
+	}
+	if opts.MaxSessions > 0 {
+		if _, err := rs.Store.PruneBySessionCount(ctx, opts.MaxSessions); err != nil {
+			log.Printf("storage: retention sweep: prune by session count: %v", err)
+		}
+	}
+}
+
+// Close stops the background sweep before delegating to the wrapped Store.
+func (rs *retentionStore) Close() error {
+	close(rs.stop)
+	return rs.Store.Close()
+}
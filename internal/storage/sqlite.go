@@ -16,38 +16,25 @@ import (
 const (
 	defaultDirName  = ".local/share/chatty"
 	defaultFileName = "chatty.db"
-	timestampLayout = time.RFC3339
 )
 
-// Store wraps access to the persistent conversation database.
-type Store struct {
-	db *sql.DB
+// SQLiteStore is the default Store backend, persisting sessions and messages
+// to a local SQLite database file.
+type SQLiteStore struct {
+	db         *sql.DB
+	fts5       bool
+	defaultTTL time.Duration
+	// encKey is the resolved 32-byte AES key used to seal/open message
+	// content when encryption is configured, or nil when it is not.
+	encKey []byte
 }
 
-// Message represents a persisted chat message.
-type Message struct {
-	Role      string
-	Content   string
-	CreatedAt time.Time
-}
-
-// SessionSummary describes a saved conversation.
-type SessionSummary struct {
-	ID           int64
-	Name         string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	MessageCount int
-}
-
-// Transcript bundles a session summary with its messages.
-type Transcript struct {
-	Summary  SessionSummary
-	Messages []Message
-}
+// OpenSQLite initialises the SQLite storage backend, creating the database
+// file if necessary. path may be empty to use the default location under the
+// user's home directory.
+func OpenSQLite(path string, opts ...Options) (*SQLiteStore, error) {
+	opt := mergeOptions(opts)
 
-// Open initialises the storage layer, creating the database if necessary.
-func Open(path string) (*Store, error) {
 	resolved, err := resolvePath(path)
 	if err != nil {
 		return nil, err
@@ -68,34 +55,50 @@ func Open(path string) (*Store, error) {
 		return nil, fmt.Errorf("set WAL journal: %w", err)
 	}
 
-	store := &Store{db: db}
+	store := &SQLiteStore{db: db, defaultTTL: opt.DefaultTTL}
 	if err := store.migrate(); err != nil {
 		db.Close()
 		return nil, err
 	}
 
+	if len(opt.EncryptionKey) > 0 {
+		salt, err := store.loadOrCreateSalt()
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		key, err := deriveKey(opt.EncryptionKey, salt)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		store.encKey = key
+	}
+
 	return store, nil
 }
 
 // Close releases underlying database resources.
-func (s *Store) Close() error {
+func (s *SQLiteStore) Close() error {
 	if s == nil || s.db == nil {
 		return nil
 	}
 	return s.db.Close()
 }
 
-func (s *Store) migrate() error {
+func (s *SQLiteStore) migrate() error {
 	stmts := []string{
 		`CREATE TABLE IF NOT EXISTS sessions (
             id INTEGER PRIMARY KEY AUTOINCREMENT,
             name TEXT NOT NULL,
             created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ','now')),
-            updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ','now'))
+            updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ','now')),
+            active_leaf_id INTEGER REFERENCES messages(id)
         );`,
 		`CREATE TABLE IF NOT EXISTS messages (
             id INTEGER PRIMARY KEY AUTOINCREMENT,
             session_id INTEGER NOT NULL,
+            parent_id INTEGER REFERENCES messages(id) ON DELETE CASCADE,
             role TEXT NOT NULL,
             content TEXT NOT NULL,
             created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ','now')),
@@ -110,11 +113,94 @@ func (s *Store) migrate() error {
 		}
 	}
 
+	// CREATE TABLE IF NOT EXISTS only applies a table's full column list on
+	// first creation, so databases from before a column existed need it
+	// added explicitly.
+	if err := s.ensureColumn("sessions", "active_leaf_id", "active_leaf_id INTEGER REFERENCES messages(id)"); err != nil {
+		return err
+	}
+	if err := s.ensureColumn("messages", "parent_id", "parent_id INTEGER REFERENCES messages(id) ON DELETE CASCADE"); err != nil {
+		return err
+	}
+	if err := s.ensureColumn("sessions", "expires_at", "expires_at TEXT"); err != nil {
+		return err
+	}
+	if err := s.ensureColumn("messages", "nonce", "nonce BLOB"); err != nil {
+		return err
+	}
+	if err := s.ensureColumn("messages", "content_encrypted", "content_encrypted INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// idx_messages_parent_id indexes a column that only ensureColumn above is
+	// guaranteed to have added, so it must run after the backfill rather than
+	// alongside the other CREATE TABLE/INDEX statements: on a database that
+	// predates parent_id, CREATE TABLE IF NOT EXISTS is a no-op and the index
+	// would otherwise fail with "no such column: parent_id".
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages(parent_id);`); err != nil {
+		return fmt.Errorf("apply migration: %w", err)
+	}
+
+	if err := s.migrateBranching(); err != nil {
+		return err
+	}
+
+	if err := s.migrateSearch(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// columnExists reports whether table has a column named column.
+func (s *SQLiteStore) columnExists(table, column string) (bool, error) {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return false, fmt.Errorf("scan column info: %w", err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("iterate column info: %w", err)
+	}
+
+	return false, nil
+}
+
+// ensureColumn adds column to table using definition if it is not already present.
+func (s *SQLiteStore) ensureColumn(table, column, definition string) error {
+	exists, err := s.columnExists(table, column)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, definition)); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
 // CreateSession inserts a new conversation row and returns its identifier.
-func (s *Store) CreateSession(ctx context.Context, name string) (int64, error) {
+func (s *SQLiteStore) CreateSession(ctx context.Context, name string) (int64, error) {
 	if s == nil || s.db == nil {
 		return 0, errors.New("storage not initialised")
 	}
@@ -124,7 +210,12 @@ func (s *Store) CreateSession(ctx context.Context, name string) (int64, error) {
 		title = fmt.Sprintf("Session %s", time.Now().Format("2006-01-02 15:04"))
 	}
 
-	res, err := s.db.ExecContext(ctx, `INSERT INTO sessions(name) VALUES (?)`, title)
+	var expiresAt any
+	if s.defaultTTL > 0 {
+		expiresAt = time.Now().Add(s.defaultTTL).UTC().Format(timestampLayout)
+	}
+
+	res, err := s.db.ExecContext(ctx, `INSERT INTO sessions(name, expires_at) VALUES (?, ?)`, title, expiresAt)
 	if err != nil {
 		return 0, fmt.Errorf("insert session: %w", err)
 	}
@@ -138,7 +229,7 @@ func (s *Store) CreateSession(ctx context.Context, name string) (int64, error) {
 }
 
 // UpdateSessionName updates the stored name for a session.
-func (s *Store) UpdateSessionName(ctx context.Context, id int64, name string) error {
+func (s *SQLiteStore) UpdateSessionName(ctx context.Context, id int64, name string) error {
 	if s == nil || s.db == nil {
 		return errors.New("storage not initialised")
 	}
@@ -159,7 +250,7 @@ func (s *Store) UpdateSessionName(ctx context.Context, id int64, name string) er
 }
 
 // AppendMessage appends a message to the specified session.
-func (s *Store) AppendMessage(ctx context.Context, sessionID int64, message Message) error {
+func (s *SQLiteStore) AppendMessage(ctx context.Context, sessionID int64, message Message) error {
 	if s == nil || s.db == nil {
 		return errors.New("storage not initialised")
 	}
@@ -170,24 +261,50 @@ func (s *Store) AppendMessage(ctx context.Context, sessionID int64, message Mess
 		return errors.New("message role cannot be empty")
 	}
 
-	if _, err := s.db.ExecContext(ctx, `INSERT INTO messages(session_id, role, content) VALUES (?, ?, ?)`, sessionID, message.Role, message.Content); err != nil {
+	var parentID sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT active_leaf_id FROM sessions WHERE id = ?`, sessionID).Scan(&parentID); err != nil {
+		return fmt.Errorf("resolve active leaf: %w", err)
+	}
+
+	content, nonce, encrypted, err := s.encryptRow(message.Content)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, `INSERT INTO messages(session_id, parent_id, role, content, nonce, content_encrypted) VALUES (?, ?, ?, ?, ?, ?)`,
+		sessionID, nullableInt64(parentID), message.Role, content, nonce, encrypted)
+	if err != nil {
 		return fmt.Errorf("insert message: %w", err)
 	}
 
-	if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET updated_at = (strftime('%Y-%m-%dT%H:%M:%SZ','now')) WHERE id = ?`, sessionID); err != nil {
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("resolve message id: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET updated_at = (strftime('%Y-%m-%dT%H:%M:%SZ','now')), active_leaf_id = ? WHERE id = ?`, newID, sessionID); err != nil {
 		return fmt.Errorf("touch session: %w", err)
 	}
 
 	return nil
 }
 
+// nullableInt64 converts a sql.NullInt64 to the value ExecContext expects,
+// passing nil for unset parents so the column stays NULL.
+func nullableInt64(v sql.NullInt64) any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Int64
+}
+
 // ListSessions returns stored conversations ordered by most recent activity.
-func (s *Store) ListSessions(ctx context.Context, limit int) ([]SessionSummary, error) {
+func (s *SQLiteStore) ListSessions(ctx context.Context, limit int) ([]SessionSummary, error) {
 	if s == nil || s.db == nil {
 		return nil, errors.New("storage not initialised")
 	}
 
-	baseQuery := `SELECT s.id, s.name, s.created_at, s.updated_at, COUNT(m.id) AS message_count
+	baseQuery := `SELECT s.id, s.name, s.created_at, s.updated_at, s.expires_at, COUNT(m.id) AS message_count
         FROM sessions s
         LEFT JOIN messages m ON m.session_id = s.id
         GROUP BY s.id
@@ -210,7 +327,8 @@ func (s *Store) ListSessions(ctx context.Context, limit int) ([]SessionSummary,
 	for rows.Next() {
 		var summary SessionSummary
 		var created, updated string
-		if err := rows.Scan(&summary.ID, &summary.Name, &created, &updated, &summary.MessageCount); err != nil {
+		var expiresAt sql.NullString
+		if err := rows.Scan(&summary.ID, &summary.Name, &created, &updated, &expiresAt, &summary.MessageCount); err != nil {
 			return nil, fmt.Errorf("scan session summary: %w", err)
 		}
 		summary.CreatedAt, err = parseTimestamp(created)
@@ -221,6 +339,10 @@ func (s *Store) ListSessions(ctx context.Context, limit int) ([]SessionSummary,
 		if err != nil {
 			return nil, err
 		}
+		summary.ExpiresAt, err = parseOptionalTimestamp(expiresAt)
+		if err != nil {
+			return nil, err
+		}
 		summaries = append(summaries, summary)
 	}
 
@@ -232,7 +354,7 @@ func (s *Store) ListSessions(ctx context.Context, limit int) ([]SessionSummary,
 }
 
 // LoadSession fetches the session metadata and full transcript for the given identifier.
-func (s *Store) LoadSession(ctx context.Context, id int64) (*Transcript, error) {
+func (s *SQLiteStore) LoadSession(ctx context.Context, id int64) (*Transcript, error) {
 	if s == nil || s.db == nil {
 		return nil, errors.New("storage not initialised")
 	}
@@ -242,12 +364,14 @@ func (s *Store) LoadSession(ctx context.Context, id int64) (*Transcript, error)
 
 	var summary SessionSummary
 	var created, updated string
-	row := s.db.QueryRowContext(ctx, `SELECT s.id, s.name, s.created_at, s.updated_at, COUNT(m.id) AS message_count
+	var activeLeafID sql.NullInt64
+	var expiresAt sql.NullString
+	row := s.db.QueryRowContext(ctx, `SELECT s.id, s.name, s.created_at, s.updated_at, s.active_leaf_id, s.expires_at, COUNT(m.id) AS message_count
         FROM sessions s
         LEFT JOIN messages m ON m.session_id = s.id
         WHERE s.id = ?
         GROUP BY s.id`, id)
-	if err := row.Scan(&summary.ID, &summary.Name, &created, &updated, &summary.MessageCount); err != nil {
+	if err := row.Scan(&summary.ID, &summary.Name, &created, &updated, &activeLeafID, &expiresAt, &summary.MessageCount); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("session %d not found", id)
 		}
@@ -263,28 +387,17 @@ func (s *Store) LoadSession(ctx context.Context, id int64) (*Transcript, error)
 	if err != nil {
 		return nil, err
 	}
-
-	rows, err := s.db.QueryContext(ctx, `SELECT role, content, created_at FROM messages WHERE session_id = ? ORDER BY id ASC`, id)
+	summary.ExpiresAt, err = parseOptionalTimestamp(expiresAt)
 	if err != nil {
-		return nil, fmt.Errorf("load messages: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	messages := make([]Message, 0, summary.MessageCount)
-	for rows.Next() {
-		var msg Message
-		var createdAt string
-		if err := rows.Scan(&msg.Role, &msg.Content, &createdAt); err != nil {
-			return nil, fmt.Errorf("scan message: %w", err)
-		}
-		msg.CreatedAt, err = parseTimestamp(createdAt)
+	var messages []Message
+	if activeLeafID.Valid {
+		messages, err = s.LoadPath(ctx, activeLeafID.Int64)
 		if err != nil {
 			return nil, err
 		}
-		messages = append(messages, msg)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate messages: %w", err)
 	}
 
 	return &Transcript{Summary: summary, Messages: messages}, nil
@@ -323,3 +436,16 @@ func parseTimestamp(value string) (time.Time, error) {
 	}
 	return t, nil
 }
+
+// parseOptionalTimestamp parses a nullable TEXT timestamp column, returning
+// nil when the column is NULL.
+func parseOptionalTimestamp(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := parseTimestamp(value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// migrateBranching backfills parent_id and active_leaf_id for databases
+// created before branching support existed, treating each session's existing
+// messages as a single linear trunk (each row's parent is the previous row).
+func (s *SQLiteStore) migrateBranching() error {
+	rows, err := s.db.Query(`SELECT id FROM sessions WHERE active_leaf_id IS NULL`)
+	if err != nil {
+		return fmt.Errorf("find sessions pending trunk backfill: %w", err)
+	}
+	var sessionIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan session id: %w", err)
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate sessions pending trunk backfill: %w", err)
+	}
+	rows.Close()
+
+	for _, sessionID := range sessionIDs {
+		if err := s.backfillTrunk(sessionID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) backfillTrunk(sessionID int64) error {
+	rows, err := s.db.Query(`SELECT id FROM messages WHERE session_id = ? ORDER BY id ASC`, sessionID)
+	if err != nil {
+		return fmt.Errorf("load trunk messages: %w", err)
+	}
+	var messageIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan trunk message id: %w", err)
+		}
+		messageIDs = append(messageIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate trunk messages: %w", err)
+	}
+	rows.Close()
+
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin trunk backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, id := range messageIDs {
+		if i == 0 {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE messages SET parent_id = ? WHERE id = ?`, messageIDs[i-1], id); err != nil {
+			return fmt.Errorf("link trunk message: %w", err)
+		}
+	}
+
+	leafID := messageIDs[len(messageIDs)-1]
+	if _, err := tx.Exec(`UPDATE sessions SET active_leaf_id = ? WHERE id = ?`, leafID, sessionID); err != nil {
+		return fmt.Errorf("set active leaf: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// BranchFromMessage forks the conversation at msgID: it inserts a sibling of
+// msgID (same session and parent, msgID's role) holding newContent, selects
+// it as the active branch for its session, and returns its new id. This is
+// the primitive behind "edit and retry as a new branch" — regenerating a
+// reply forks rather than overwrites the original.
+func (s *SQLiteStore) BranchFromMessage(ctx context.Context, msgID int64, newContent string) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("storage not initialised")
+	}
+	if msgID <= 0 {
+		return 0, errors.New("invalid message id")
+	}
+
+	var sessionID int64
+	var parentID sql.NullInt64
+	var role string
+	row := s.db.QueryRowContext(ctx, `SELECT session_id, parent_id, role FROM messages WHERE id = ?`, msgID)
+	if err := row.Scan(&sessionID, &parentID, &role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("message %d not found", msgID)
+		}
+		return 0, fmt.Errorf("select message: %w", err)
+	}
+
+	content, nonce, encrypted, err := s.encryptRow(newContent)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.db.ExecContext(ctx, `INSERT INTO messages(session_id, parent_id, role, content, nonce, content_encrypted) VALUES (?, ?, ?, ?, ?, ?)`,
+		sessionID, nullableInt64(parentID), role, content, nonce, encrypted)
+	if err != nil {
+		return 0, fmt.Errorf("insert branch: %w", err)
+	}
+
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("resolve branch id: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET updated_at = (strftime('%Y-%m-%dT%H:%M:%SZ','now')), active_leaf_id = ? WHERE id = ?`, newID, sessionID); err != nil {
+		return 0, fmt.Errorf("select branch: %w", err)
+	}
+
+	return newID, nil
+}
+
+// SwitchBranch makes messageID the active leaf of sessionID, so subsequent
+// LoadSession and AppendMessage calls follow the path ending at messageID.
+func (s *SQLiteStore) SwitchBranch(ctx context.Context, sessionID, messageID int64) error {
+	if s == nil || s.db == nil {
+		return errors.New("storage not initialised")
+	}
+	if sessionID <= 0 {
+		return errors.New("invalid session id")
+	}
+	if messageID <= 0 {
+		return errors.New("invalid message id")
+	}
+
+	var owner int64
+	if err := s.db.QueryRowContext(ctx, `SELECT session_id FROM messages WHERE id = ?`, messageID).Scan(&owner); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("message %d not found", messageID)
+		}
+		return fmt.Errorf("select message: %w", err)
+	}
+	if owner != sessionID {
+		return fmt.Errorf("message %d does not belong to session %d", messageID, sessionID)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET active_leaf_id = ? WHERE id = ?`, messageID, sessionID); err != nil {
+		return fmt.Errorf("switch branch: %w", err)
+	}
+
+	return nil
+}
+
+// ListChildren returns the direct children of messageID ordered by creation,
+// i.e. the sibling branches a user can switch between at that point.
+func (s *SQLiteStore) ListChildren(ctx context.Context, messageID int64) ([]Message, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, parent_id, role, content, created_at, content_encrypted, nonce FROM messages WHERE parent_id = ? ORDER BY id ASC`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("list children: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanMessages(rows)
+}
+
+// LoadPath walks the parent chain from leafID back to its trunk root and
+// returns the messages in chronological order.
+func (s *SQLiteStore) LoadPath(ctx context.Context, leafID int64) ([]Message, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+	if leafID <= 0 {
+		return nil, errors.New("invalid message id")
+	}
+
+	var path []Message
+	currentID := sql.NullInt64{Int64: leafID, Valid: true}
+	for currentID.Valid {
+		var msg Message
+		var parentID sql.NullInt64
+		var createdAt string
+		var encryptedFlag bool
+		var nonce []byte
+		row := s.db.QueryRowContext(ctx, `SELECT id, parent_id, role, content, created_at, content_encrypted, nonce FROM messages WHERE id = ?`, currentID.Int64)
+		if err := row.Scan(&msg.ID, &parentID, &msg.Role, &msg.Content, &createdAt, &encryptedFlag, &nonce); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("message %d not found", currentID.Int64)
+			}
+			return nil, fmt.Errorf("select message: %w", err)
+		}
+
+		var err error
+		msg.CreatedAt, err = parseTimestamp(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		msg.Content, err = s.decryptRow(msg.Content, nonce, encryptedFlag)
+		if err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := parentID.Int64
+			msg.ParentID = &id
+		}
+
+		path = append(path, msg)
+		currentID = parentID
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path, nil
+}
+
+func (s *SQLiteStore) scanMessages(rows *sql.Rows) ([]Message, error) {
+	messages := make([]Message, 0, 8)
+	for rows.Next() {
+		var msg Message
+		var parentID sql.NullInt64
+		var createdAt string
+		var encryptedFlag bool
+		var nonce []byte
+		if err := rows.Scan(&msg.ID, &parentID, &msg.Role, &msg.Content, &createdAt, &encryptedFlag, &nonce); err != nil {
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		var err error
+		msg.CreatedAt, err = parseTimestamp(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		msg.Content, err = s.decryptRow(msg.Content, nonce, encryptedFlag)
+		if err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := parentID.Int64
+			msg.ParentID = &id
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate messages: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,410 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportSession writes the session's active transcript to w in the given format.
+func (s *SQLiteStore) ExportSession(ctx context.Context, id int64, format ExportFormat, w io.Writer) error {
+	if s == nil || s.db == nil {
+		return errors.New("storage not initialised")
+	}
+
+	tr, err := s.LoadSession(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		return encodeSessionJSON(tr, w)
+	case FormatMarkdown:
+		return encodeSessionMarkdown(tr, w)
+	default:
+		return fmt.Errorf("unsupported export format: %d", format)
+	}
+}
+
+// ImportSession reads a transcript in the given format from r, creates a new
+// session for it, and returns the new session id. Message order and
+// timestamps from the source are preserved.
+func (s *SQLiteStore) ImportSession(ctx context.Context, format ExportFormat, r io.Reader) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("storage not initialised")
+	}
+
+	name, messages, err := decodeSessionExport(format, r)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	sessionID, err := insertSessionTx(ctx, tx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.insertTrunkTx(ctx, tx, sessionID, messages); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit import: %w", err)
+	}
+
+	return sessionID, nil
+}
+
+func insertSessionTx(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
+	title := strings.TrimSpace(name)
+	if title == "" {
+		title = "Imported session"
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO sessions(name) VALUES (?)`, title)
+	if err != nil {
+		return 0, fmt.Errorf("insert session: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("resolve session id: %w", err)
+	}
+	return id, nil
+}
+
+// insertTrunkTx inserts messages as a single linear trunk under sessionID,
+// preserving their order and timestamps, and leaves the last message
+// selected as the session's active leaf.
+func (s *SQLiteStore) insertTrunkTx(ctx context.Context, tx *sql.Tx, sessionID int64, messages []exportedMessage) error {
+	var parentID sql.NullInt64
+	var leafID int64
+
+	for _, m := range messages {
+		content, nonce, encrypted, err := s.encryptRow(m.Content)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO messages(session_id, parent_id, role, content, created_at, nonce, content_encrypted) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			sessionID, nullableInt64(parentID), m.Role, content, m.CreatedAt.UTC().Format(timestampLayout), nonce, encrypted)
+		if err != nil {
+			return fmt.Errorf("insert message: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("resolve message id: %w", err)
+		}
+		parentID = sql.NullInt64{Int64: id, Valid: true}
+		leafID = id
+	}
+
+	if leafID != 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET active_leaf_id = ?, updated_at = (strftime('%Y-%m-%dT%H:%M:%SZ','now')) WHERE id = ?`, leafID, sessionID); err != nil {
+			return fmt.Errorf("set active leaf: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ExportAll streams every session as one NDJSON line per session, suitable
+// for backup or migrating a whole database at once.
+func (s *SQLiteStore) ExportAll(ctx context.Context, w io.Writer) error {
+	if s == nil || s.db == nil {
+		return errors.New("storage not initialised")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM sessions ORDER BY id ASC`)
+	if err != nil {
+		return fmt.Errorf("list sessions: %w", err)
+	}
+	var sessionIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan session id: %w", err)
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate sessions: %w", err)
+	}
+	rows.Close()
+
+	enc := json.NewEncoder(w)
+	for _, id := range sessionIDs {
+		tr, err := s.LoadSession(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(transcriptToExported(tr)); err != nil {
+			return fmt.Errorf("encode session %d: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// CloneSession duplicates a session and every message in its tree (all
+// branches, not just the active path) under newName, in a single transaction.
+func (s *SQLiteStore) CloneSession(ctx context.Context, id int64, newName string) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("storage not initialised")
+	}
+	if id <= 0 {
+		return 0, errors.New("invalid session id")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin clone: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sourceName string
+	var activeLeafID sql.NullInt64
+	row := tx.QueryRowContext(ctx, `SELECT name, active_leaf_id FROM sessions WHERE id = ?`, id)
+	if err := row.Scan(&sourceName, &activeLeafID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("session %d not found", id)
+		}
+		return 0, fmt.Errorf("select session: %w", err)
+	}
+
+	title := strings.TrimSpace(newName)
+	if title == "" {
+		title = sourceName + " (copy)"
+	}
+	newID, err := insertSessionTx(ctx, tx, title)
+	if err != nil {
+		return 0, err
+	}
+
+	sourceRows, err := loadMessageRowsTx(ctx, tx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	idMap := make(map[int64]int64, len(sourceRows))
+	var newActiveLeaf sql.NullInt64
+	for _, r := range sourceRows {
+		var newParent sql.NullInt64
+		if r.parentID.Valid {
+			mapped, ok := idMap[r.parentID.Int64]
+			if !ok {
+				return 0, fmt.Errorf("clone session: parent %d seen before message %d", r.parentID.Int64, r.id)
+			}
+			newParent = sql.NullInt64{Int64: mapped, Valid: true}
+		}
+
+		// Cloned messages keep their existing ciphertext and nonce as-is: the
+		// content is unchanged, so it stays valid under the same encryption key.
+		res, err := tx.ExecContext(ctx, `INSERT INTO messages(session_id, parent_id, role, content, created_at, nonce, content_encrypted) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			newID, nullableInt64(newParent), r.role, r.content, r.createdAt, r.nonce, r.encrypted)
+		if err != nil {
+			return 0, fmt.Errorf("insert cloned message: %w", err)
+		}
+		newMsgID, err := res.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("resolve cloned message id: %w", err)
+		}
+		idMap[r.id] = newMsgID
+		if activeLeafID.Valid && r.id == activeLeafID.Int64 {
+			newActiveLeaf = sql.NullInt64{Int64: newMsgID, Valid: true}
+		}
+	}
+
+	if newActiveLeaf.Valid {
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET active_leaf_id = ? WHERE id = ?`, newActiveLeaf.Int64, newID); err != nil {
+			return 0, fmt.Errorf("set cloned active leaf: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit clone: %w", err)
+	}
+
+	return newID, nil
+}
+
+// ExportSessionTree returns a full-fidelity snapshot of id's entire message
+// tree (every branch, not just the active path), for cross-backend migration.
+func (s *SQLiteStore) ExportSessionTree(ctx context.Context, id int64) (*SessionTree, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+	if id <= 0 {
+		return nil, errors.New("invalid session id")
+	}
+
+	var name, created, updated string
+	var activeLeafID sql.NullInt64
+	row := s.db.QueryRowContext(ctx, `SELECT name, created_at, updated_at, active_leaf_id FROM sessions WHERE id = ?`, id)
+	if err := row.Scan(&name, &created, &updated, &activeLeafID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("session %d not found", id)
+		}
+		return nil, fmt.Errorf("select session: %w", err)
+	}
+
+	tree := &SessionTree{Name: name}
+	var err error
+	tree.CreatedAt, err = parseTimestamp(created)
+	if err != nil {
+		return nil, err
+	}
+	tree.UpdatedAt, err = parseTimestamp(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := loadMessageRowsTx(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	seqByID := make(map[int64]int, len(rows))
+	for _, r := range rows {
+		content, err := s.decryptRow(r.content, r.nonce, r.encrypted)
+		if err != nil {
+			return nil, err
+		}
+		createdAt, err := parseTimestamp(r.createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		seq := len(tree.Messages) + 1
+		var parentSeq int
+		if r.parentID.Valid {
+			parentSeq = seqByID[r.parentID.Int64]
+		}
+		tree.Messages = append(tree.Messages, SessionTreeMessage{
+			Seq: seq, ParentSeq: parentSeq, Role: r.role, Content: content, CreatedAt: createdAt,
+		})
+		seqByID[r.id] = seq
+		if activeLeafID.Valid && r.id == activeLeafID.Int64 {
+			tree.ActiveLeafSeq = seq
+		}
+	}
+
+	return tree, nil
+}
+
+// ImportSessionTree recreates a session from a snapshot produced by
+// ExportSessionTree, preserving its branch structure, in a single transaction.
+func (s *SQLiteStore) ImportSessionTree(ctx context.Context, tree *SessionTree) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("storage not initialised")
+	}
+	if tree == nil {
+		return 0, errors.New("session tree cannot be nil")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tree import: %w", err)
+	}
+	defer tx.Rollback()
+
+	sessionID, err := insertSessionTx(ctx, tx, tree.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	idBySeq := make(map[int]int64, len(tree.Messages))
+	var activeLeafID int64
+	for _, m := range tree.Messages {
+		content, nonce, encrypted, err := s.encryptRow(m.Content)
+		if err != nil {
+			return 0, err
+		}
+
+		var parentID sql.NullInt64
+		if m.ParentSeq > 0 {
+			parent, ok := idBySeq[m.ParentSeq]
+			if !ok {
+				return 0, fmt.Errorf("import session tree: parent seq %d seen before message seq %d", m.ParentSeq, m.Seq)
+			}
+			parentID = sql.NullInt64{Int64: parent, Valid: true}
+		}
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO messages(session_id, parent_id, role, content, created_at, nonce, content_encrypted) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			sessionID, nullableInt64(parentID), m.Role, content, m.CreatedAt.UTC().Format(timestampLayout), nonce, encrypted)
+		if err != nil {
+			return 0, fmt.Errorf("insert message: %w", err)
+		}
+		newID, err := res.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("resolve message id: %w", err)
+		}
+		idBySeq[m.Seq] = newID
+		if m.Seq == tree.ActiveLeafSeq {
+			activeLeafID = newID
+		}
+	}
+
+	if activeLeafID != 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET active_leaf_id = ?, updated_at = (strftime('%Y-%m-%dT%H:%M:%SZ','now')) WHERE id = ?`, activeLeafID, sessionID); err != nil {
+			return 0, fmt.Errorf("set active leaf: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit tree import: %w", err)
+	}
+
+	return sessionID, nil
+}
+
+type messageRow struct {
+	id        int64
+	parentID  sql.NullInt64
+	role      string
+	content   string
+	createdAt string
+	nonce     []byte
+	encrypted bool
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so loadMessageRowsTx can
+// read either directly (ExportSessionTree) or within a transaction
+// (CloneSession).
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func loadMessageRowsTx(ctx context.Context, tx querier, sessionID int64) ([]messageRow, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, parent_id, role, content, created_at, content_encrypted, nonce FROM messages WHERE session_id = ? ORDER BY id ASC`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("load source messages: %w", err)
+	}
+	defer rows.Close()
+
+	var result []messageRow
+	for rows.Next() {
+		var r messageRow
+		if err := rows.Scan(&r.id, &r.parentID, &r.role, &r.content, &r.createdAt, &r.encrypted, &r.nonce); err != nil {
+			return nil, fmt.Errorf("scan source message: %w", err)
+		}
+		result = append(result, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate source messages: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// TestSQLiteExportImportRoundTrip_PreservesOrderAndTimestamps guards the
+// round-trip guarantee ExportSession/ImportSession are supposed to provide:
+// messages must come back in the same order with the same timestamps,
+// regardless of format. AppendMessage assigns created_at itself (it ignores
+// the CreatedAt on the Message passed in), so the expected values are
+// whatever the original session actually recorded, not values the test picks.
+func TestSQLiteExportImportRoundTrip_PreservesOrderAndTimestamps(t *testing.T) {
+	ctx := context.Background()
+	store, err := OpenSQLite(filepath.Join(t.TempDir(), "chatty.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	sessionID, err := store.CreateSession(ctx, "round trip")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	seed := []Message{
+		{Role: "user", Content: "hello"},
+		{Role: "assistant", Content: "```go\nfmt.Println(\"hi\")\n```"},
+		{Role: "user", Content: "thanks"},
+	}
+	for _, m := range seed {
+		if err := store.AppendMessage(ctx, sessionID, m); err != nil {
+			t.Fatalf("append message: %v", err)
+		}
+	}
+
+	original, err := store.LoadSession(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("load original session: %v", err)
+	}
+	want := original.Messages
+
+	for _, format := range []ExportFormat{FormatJSON, FormatMarkdown} {
+		var buf bytes.Buffer
+		if err := store.ExportSession(ctx, sessionID, format, &buf); err != nil {
+			t.Fatalf("export (format %d): %v", format, err)
+		}
+
+		newID, err := store.ImportSession(ctx, format, &buf)
+		if err != nil {
+			t.Fatalf("import (format %d): %v", format, err)
+		}
+
+		tr, err := store.LoadSession(ctx, newID)
+		if err != nil {
+			t.Fatalf("load imported session (format %d): %v", format, err)
+		}
+		if len(tr.Messages) != len(want) {
+			t.Fatalf("format %d: want %d messages, got %d: %+v", format, len(want), len(tr.Messages), tr.Messages)
+		}
+		for i, w := range want {
+			got := tr.Messages[i]
+			if got.Role != w.Role || got.Content != w.Content {
+				t.Fatalf("format %d: message %d mismatch: want %+v, got %+v", format, i, w, got)
+			}
+			if !got.CreatedAt.Equal(w.CreatedAt) {
+				t.Fatalf("format %d: message %d timestamp mismatch: want %v, got %v", format, i, w.CreatedAt, got.CreatedAt)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PruneOlderThan deletes every session whose expires_at is set and at or
+// before cutoff, along with its messages, and returns how many of each were
+// removed.
+func (s *SQLiteStore) PruneOlderThan(ctx context.Context, cutoff time.Time) (deletedSessions, deletedMessages int, err error) {
+	if s == nil || s.db == nil {
+		return 0, 0, errors.New("storage not initialised")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin prune: %w", err)
+	}
+	defer tx.Rollback()
+
+	cutoffStr := cutoff.UTC().Format(timestampLayout)
+
+	var msgCount int64
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE session_id IN (
+        SELECT id FROM sessions WHERE expires_at IS NOT NULL AND expires_at <= ?
+    )`, cutoffStr).Scan(&msgCount); err != nil {
+		return 0, 0, fmt.Errorf("count pruned messages: %w", err)
+	}
+
+	// Clear active_leaf_id first: sessions.active_leaf_id references
+	// messages(id) with no ON DELETE action, so it would otherwise block the
+	// cascading message delete below.
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET active_leaf_id = NULL WHERE expires_at IS NOT NULL AND expires_at <= ?`, cutoffStr); err != nil {
+		return 0, 0, fmt.Errorf("clear active leaf before prune: %w", err)
+	}
+
+	// Deleting the session cascades to its messages via ON DELETE CASCADE.
+	sessRes, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at IS NOT NULL AND expires_at <= ?`, cutoffStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("prune sessions: %w", err)
+	}
+	sessCount, err := sessRes.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("count pruned sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit prune: %w", err)
+	}
+
+	// VACUUM rewrites the whole database file under an exclusive lock, and
+	// the store is opened with SetMaxOpenConns(1), so it stalls every other
+	// caller (including the periodic retention sweep) for as long as the
+	// rewrite takes. Only pay for it when pruning actually freed space.
+	if sessCount > 0 {
+		if _, err := s.db.ExecContext(ctx, `VACUUM`); err != nil {
+			return int(sessCount), int(msgCount), fmt.Errorf("vacuum after prune: %w", err)
+		}
+	}
+
+	return int(sessCount), int(msgCount), nil
+}
+
+// PruneBySessionCount deletes the oldest sessions (by updated_at) beyond
+// keepMostRecent, along with their messages, and returns how many sessions
+// were removed.
+func (s *SQLiteStore) PruneBySessionCount(ctx context.Context, keepMostRecent int) (int, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("storage not initialised")
+	}
+	if keepMostRecent < 0 {
+		return 0, errors.New("keepMostRecent cannot be negative")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin prune: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Clear active_leaf_id first: sessions.active_leaf_id references
+	// messages(id) with no ON DELETE action, so it would otherwise block the
+	// cascading message delete below.
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET active_leaf_id = NULL WHERE id IN (
+        SELECT id FROM sessions ORDER BY updated_at DESC LIMIT -1 OFFSET ?
+    )`, keepMostRecent); err != nil {
+		return 0, fmt.Errorf("clear active leaf before prune: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE id IN (
+        SELECT id FROM sessions ORDER BY updated_at DESC LIMIT -1 OFFSET ?
+    )`, keepMostRecent)
+	if err != nil {
+		return 0, fmt.Errorf("prune by session count: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("count pruned sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit prune: %w", err)
+	}
+
+	return int(count), nil
+}
+
+// SetSessionTTL sets sessionID's expiry to ttl from now, or clears it when
+// ttl is zero or negative.
+func (s *SQLiteStore) SetSessionTTL(ctx context.Context, sessionID int64, ttl time.Duration) error {
+	if s == nil || s.db == nil {
+		return errors.New("storage not initialised")
+	}
+	if sessionID <= 0 {
+		return errors.New("invalid session id")
+	}
+
+	var expiresAt sql.NullString
+	if ttl > 0 {
+		expiresAt = sql.NullString{String: time.Now().Add(ttl).UTC().Format(timestampLayout), Valid: true}
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE sessions SET expires_at = ? WHERE id = ?`, nullableString(expiresAt), sessionID)
+	if err != nil {
+		return fmt.Errorf("set session ttl: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("confirm session ttl update: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("session %d not found", sessionID)
+	}
+
+	return nil
+}
+
+func nullableString(v sql.NullString) any {
+	if !v.Valid {
+		return nil
+	}
+	return v.String
+}
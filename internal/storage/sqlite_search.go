@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migrateSearch creates the FTS5 shadow index used by SearchMessages and the
+// triggers that keep it in sync with the messages table. If the sqlite build
+// lacks FTS5, s.fts5 is left false and SearchMessages falls back to LIKE scans.
+func (s *SQLiteStore) migrateSearch() error {
+	_, err := s.db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+        content,
+        content='messages',
+        content_rowid='id'
+    );`)
+	if err != nil {
+		if isMissingFTS5(err) {
+			s.fts5 = false
+			return nil
+		}
+		return fmt.Errorf("create fts index: %w", err)
+	}
+	s.fts5 = true
+
+	triggers := []string{
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_ai AFTER INSERT ON messages BEGIN
+            INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+        END;`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_ad AFTER DELETE ON messages BEGIN
+            INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+        END;`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_au AFTER UPDATE ON messages BEGIN
+            INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+            INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+        END;`,
+	}
+	for _, stmt := range triggers {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("create fts trigger: %w", err)
+		}
+	}
+
+	// Backfill rows that predate the index (or the triggers missing them).
+	if _, err := s.db.Exec(`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`); err != nil {
+		return fmt.Errorf("backfill fts index: %w", err)
+	}
+
+	return nil
+}
+
+func isMissingFTS5(err error) bool {
+	return strings.Contains(err.Error(), "no such module: fts5")
+}
+
+// sqliteActivePathCTE is a recursive CTE shared by SearchMessages and the
+// MessagesBefore/After pagination queries. It anchors on every session's
+// active_leaf_id and walks parent_id back to each root, producing the set of
+// message ids that are actually reachable from LoadSession for some session
+// rather than sitting on an abandoned branch. Queries join against it so
+// paging and search never surface a message that LoadSession wouldn't.
+const sqliteActivePathCTE = `WITH RECURSIVE active_path(id) AS (
+    SELECT active_leaf_id AS id FROM sessions WHERE active_leaf_id IS NOT NULL
+    UNION ALL
+    SELECT m.parent_id FROM messages m JOIN active_path a ON m.id = a.id WHERE m.parent_id IS NOT NULL
+)
+`
+
+// SearchMessages searches stored message content, using the FTS5 index when
+// available and falling back to a LIKE-based scan otherwise. Both paths
+// match against the stored column as-is, so when Options.EncryptionKey is
+// set, encrypted messages are ciphertext and will not be found; full-text
+// search over at-rest encrypted content is not supported. Results are
+// restricted to each message's session's active branch, matching LoadSession.
+func (s *SQLiteStore) SearchMessages(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+	if strings.TrimSpace(query.Match) == "" {
+		return nil, errors.New("search match cannot be empty")
+	}
+
+	if s.fts5 {
+		return s.searchMessagesFTS(ctx, query)
+	}
+	return s.searchMessagesLike(ctx, query)
+}
+
+func (s *SQLiteStore) searchMessagesFTS(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	sqlQuery := strings.Builder{}
+	sqlQuery.WriteString(sqliteActivePathCTE)
+	sqlQuery.WriteString(`SELECT m.session_id, m.id, m.role, m.created_at,
+        snippet(messages_fts, 0, '[', ']', '...', 10) AS snippet,
+        bm25(messages_fts) AS rank
+        FROM messages_fts
+        JOIN messages m ON m.id = messages_fts.rowid
+        WHERE messages_fts MATCH ? AND m.id IN (SELECT id FROM active_path)`)
+	args := []any{query.Match}
+
+	args = appendSearchFilters(&sqlQuery, args, query)
+	sqlQuery.WriteString(" ORDER BY rank ASC")
+	if query.Limit > 0 {
+		sqlQuery.WriteString(" LIMIT ?")
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSearchHits(rows)
+}
+
+func (s *SQLiteStore) searchMessagesLike(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	sqlQuery := strings.Builder{}
+	sqlQuery.WriteString(sqliteActivePathCTE)
+	sqlQuery.WriteString(`SELECT m.session_id, m.id, m.role, m.created_at, m.content, 0.0 AS rank
+        FROM messages m
+        WHERE m.content LIKE ? ESCAPE '\' AND m.id IN (SELECT id FROM active_path)`)
+	args := []any{"%" + escapeLike(query.Match) + "%"}
+
+	args = appendSearchFilters(&sqlQuery, args, query)
+	sqlQuery.WriteString(" ORDER BY m.id DESC")
+	if query.Limit > 0 {
+		sqlQuery.WriteString(" LIMIT ?")
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	defer rows.Close()
+
+	hits := make([]SearchHit, 0, 16)
+	for rows.Next() {
+		var hit SearchHit
+		var createdAt, content string
+		if err := rows.Scan(&hit.SessionID, &hit.MessageID, &hit.Role, &createdAt, &content, &hit.Rank); err != nil {
+			return nil, fmt.Errorf("scan search hit: %w", err)
+		}
+		hit.CreatedAt, err = parseTimestamp(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		hit.Snippet = snippetAround(content, query.Match, 40)
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate search hits: %w", err)
+	}
+
+	return hits, nil
+}
+
+func appendSearchFilters(sqlQuery *strings.Builder, args []any, query SearchQuery) []any {
+	if query.SessionID > 0 {
+		sqlQuery.WriteString(" AND m.session_id = ?")
+		args = append(args, query.SessionID)
+	}
+	if role := strings.TrimSpace(query.Role); role != "" {
+		sqlQuery.WriteString(" AND m.role = ?")
+		args = append(args, role)
+	}
+	if !query.Since.IsZero() {
+		sqlQuery.WriteString(" AND m.created_at >= ?")
+		args = append(args, query.Since.UTC().Format(timestampLayout))
+	}
+	if !query.Until.IsZero() {
+		sqlQuery.WriteString(" AND m.created_at <= ?")
+		args = append(args, query.Until.UTC().Format(timestampLayout))
+	}
+	return args
+}
+
+func scanSearchHits(rows *sql.Rows) ([]SearchHit, error) {
+	hits := make([]SearchHit, 0, 16)
+	for rows.Next() {
+		var hit SearchHit
+		var createdAt string
+		if err := rows.Scan(&hit.SessionID, &hit.MessageID, &hit.Role, &createdAt, &hit.Snippet, &hit.Rank); err != nil {
+			return nil, fmt.Errorf("scan search hit: %w", err)
+		}
+		t, err := parseTimestamp(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		hit.CreatedAt = t
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate search hits: %w", err)
+	}
+	return hits, nil
+}
+
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
+
+// snippetAround returns a short excerpt of content centred on the first
+// case-insensitive occurrence of match, used by the LIKE fallback path.
+func snippetAround(content, match string, radius int) string {
+	idx := strings.Index(strings.ToLower(content), strings.ToLower(match))
+	if idx < 0 {
+		if len(content) <= radius*2 {
+			return content
+		}
+		return content[:radius*2] + "..."
+	}
+
+	start := idx - radius
+	prefix := ""
+	if start <= 0 {
+		start = 0
+	} else {
+		prefix = "..."
+	}
+
+	end := idx + len(match) + radius
+	suffix := ""
+	if end >= len(content) {
+		end = len(content)
+	} else {
+		suffix = "..."
+	}
+
+	return prefix + content[start:end] + suffix
+}
+
+// MessagesBefore returns up to limit messages from sessionID's active branch
+// that were created strictly before cursor. Results are the limit messages
+// closest to cursor, returned in chronological order, for CHATHISTORY-style
+// backward pagination.
+func (s *SQLiteStore) MessagesBefore(ctx context.Context, sessionID int64, cursor time.Time, limit int) ([]Message, error) {
+	return s.messagesAround(ctx, sessionID, cursor, limit, "<", "DESC")
+}
+
+// MessagesAfter returns up to limit messages from sessionID's active branch
+// that were created strictly after cursor, ordered oldest-first, for
+// CHATHISTORY-style forward pagination.
+func (s *SQLiteStore) MessagesAfter(ctx context.Context, sessionID int64, cursor time.Time, limit int) ([]Message, error) {
+	return s.messagesAround(ctx, sessionID, cursor, limit, ">", "ASC")
+}
+
+// messagesAround restricts to sessionID's active branch (the same path
+// LoadSession walks), so an abandoned branch created by BranchFromMessage
+// never interleaves with the live conversation in a paginated view.
+func (s *SQLiteStore) messagesAround(ctx context.Context, sessionID int64, cursor time.Time, limit int, op, order string) ([]Message, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("storage not initialised")
+	}
+	if sessionID <= 0 {
+		return nil, errors.New("invalid session id")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	query := fmt.Sprintf(sqliteActivePathCTE+`SELECT id, parent_id, role, content, created_at, content_encrypted, nonce FROM messages
+        WHERE session_id = ? AND id IN (SELECT id FROM active_path) AND created_at %s ?
+        ORDER BY created_at %s, id %s
+        LIMIT ?`, op, order, order)
+
+	rows, err := s.db.QueryContext(ctx, query, sessionID, cursor.UTC().Format(timestampLayout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("page messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make([]Message, 0, limit)
+	for rows.Next() {
+		var msg Message
+		var parentID sql.NullInt64
+		var createdAt string
+		var encryptedFlag bool
+		var nonce []byte
+		if err := rows.Scan(&msg.ID, &parentID, &msg.Role, &msg.Content, &createdAt, &encryptedFlag, &nonce); err != nil {
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		if parentID.Valid {
+			id := parentID.Int64
+			msg.ParentID = &id
+		}
+		msg.CreatedAt, err = parseTimestamp(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		msg.Content, err = s.decryptRow(msg.Content, nonce, encryptedFlag)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate messages: %w", err)
+	}
+
+	if order == "DESC" {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// TestOpenSQLite_MigratesPreSeriesSchema guards against regressions in the
+// ensureColumn/index ordering: a database created before parent_id,
+// active_leaf_id, and friends existed must still open cleanly, with its
+// existing messages backfilled into a single trunk.
+func TestOpenSQLite_MigratesPreSeriesSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chatty.db")
+
+	legacy, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open pre-series db: %v", err)
+	}
+	if _, err := legacy.Exec(`CREATE TABLE sessions (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL,
+        created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ','now')),
+        updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ','now'))
+    )`); err != nil {
+		t.Fatalf("create pre-series sessions table: %v", err)
+	}
+	if _, err := legacy.Exec(`CREATE TABLE messages (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        session_id INTEGER NOT NULL,
+        role TEXT NOT NULL,
+        content TEXT NOT NULL,
+        created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ','now'))
+    )`); err != nil {
+		t.Fatalf("create pre-series messages table: %v", err)
+	}
+
+	res, err := legacy.Exec(`INSERT INTO sessions(name) VALUES ('legacy session')`)
+	if err != nil {
+		t.Fatalf("seed session: %v", err)
+	}
+	sessionID, err := res.LastInsertId()
+	if err != nil {
+		t.Fatalf("resolve seeded session id: %v", err)
+	}
+	for _, role := range []string{"user", "assistant"} {
+		if _, err := legacy.Exec(`INSERT INTO messages(session_id, role, content) VALUES (?, ?, ?)`,
+			sessionID, role, role+" content"); err != nil {
+			t.Fatalf("seed message: %v", err)
+		}
+	}
+	if err := legacy.Close(); err != nil {
+		t.Fatalf("close pre-series db: %v", err)
+	}
+
+	store, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite on pre-series schema: %v", err)
+	}
+	defer store.Close()
+
+	tr, err := store.LoadSession(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("LoadSession: %v", err)
+	}
+	if len(tr.Messages) != 2 {
+		t.Fatalf("want 2 trunk messages, got %d: %+v", len(tr.Messages), tr.Messages)
+	}
+	if tr.Messages[0].Role != "user" || tr.Messages[1].Role != "assistant" {
+		t.Fatalf("unexpected trunk order/roles: %+v", tr.Messages)
+	}
+	if tr.Messages[1].ParentID == nil || *tr.Messages[1].ParentID != tr.Messages[0].ID {
+		t.Fatalf("expected second message's parent to be the first: %+v", tr.Messages)
+	}
+}
@@ -0,0 +1,222 @@
+// Package storage persists chat sessions and messages behind a Store
+// interface, with SQLite, Postgres, and in-memory backends.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// timestampLayout is the textual timestamp format used wherever backends or
+// export formats represent a time.Time as a string.
+const timestampLayout = time.RFC3339
+
+// Message represents a persisted chat message.
+type Message struct {
+	ID        int64
+	ParentID  *int64
+	Role      string
+	Content   string
+	CreatedAt time.Time
+}
+
+// SessionSummary describes a saved conversation.
+type SessionSummary struct {
+	ID           int64
+	Name         string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	MessageCount int
+	// ExpiresAt is when the session becomes eligible for pruning by
+	// PruneOlderThan, or nil if it has no TTL.
+	ExpiresAt *time.Time
+}
+
+// Transcript bundles a session summary with its messages.
+type Transcript struct {
+	Summary  SessionSummary
+	Messages []Message
+}
+
+// SearchQuery describes a full-text search over stored messages.
+type SearchQuery struct {
+	// SessionID restricts the search to a single session. Zero searches all sessions.
+	SessionID int64
+	// Role restricts results to a single message role (e.g. "user", "assistant"). Empty matches any role.
+	Role string
+	// Since and Until bound the message's CreatedAt, inclusive. Zero values are unbounded.
+	Since time.Time
+	Until time.Time
+	// Match is the search text. Backends that support a native full-text
+	// query language (FTS5, tsquery) pass it through as-is; others fall back
+	// to a case-insensitive substring match.
+	Match string
+	// Limit caps the number of hits returned. Zero means no limit.
+	Limit int
+}
+
+// SessionTreeMessage is one message within a SessionTree. Seq is its
+// 1-based position within SessionTree.Messages, and ParentSeq references
+// another message's Seq (or 0 for a root), so the tree can be carried
+// between backends whose id spaces don't match.
+type SessionTreeMessage struct {
+	Seq       int
+	ParentSeq int
+	Role      string
+	Content   string
+	CreatedAt time.Time
+}
+
+// SessionTree is a full-fidelity, backend-agnostic snapshot of a session's
+// entire message tree, including branches left behind by
+// BranchFromMessage/SwitchBranch, not just the active path ExportSession
+// serializes. It exists for cross-backend migration (see contrib/migrate-store)
+// so moving history between backends doesn't silently drop edited or
+// regenerated turns.
+type SessionTree struct {
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Messages  []SessionTreeMessage
+	// ActiveLeafSeq is the Seq of the message that should become the
+	// imported session's active leaf, or 0 if the session has no messages.
+	ActiveLeafSeq int
+}
+
+// SearchHit is a single match produced by SearchMessages.
+type SearchHit struct {
+	SessionID int64
+	MessageID int64
+	Role      string
+	CreatedAt time.Time
+	// Snippet is a short excerpt of the matching content with the match highlighted.
+	Snippet string
+	// Rank is the match's relevance score from the backend's native ranking;
+	// lower is better. It is always zero for backends without native ranking.
+	Rank float64
+}
+
+// Store is the interface implemented by every storage backend. Callers
+// should depend on this interface rather than a concrete backend type.
+type Store interface {
+	// CreateSession inserts a new conversation and returns its identifier.
+	CreateSession(ctx context.Context, name string) (int64, error)
+	// UpdateSessionName updates the stored name for a session.
+	UpdateSessionName(ctx context.Context, id int64, name string) error
+	// AppendMessage appends a message to the specified session, continuing
+	// its currently selected branch.
+	AppendMessage(ctx context.Context, sessionID int64, message Message) error
+	// ListSessions returns stored conversations ordered by most recent activity.
+	ListSessions(ctx context.Context, limit int) ([]SessionSummary, error)
+	// LoadSession fetches the session metadata and the transcript of its
+	// currently selected branch.
+	LoadSession(ctx context.Context, id int64) (*Transcript, error)
+
+	// SearchMessages searches stored message content. Like LoadSession, it
+	// only considers each session's active branch: messages left behind by
+	// BranchFromMessage/SwitchBranch are excluded.
+	SearchMessages(ctx context.Context, query SearchQuery) ([]SearchHit, error)
+	// MessagesBefore returns messages from sessionID's active branch created
+	// strictly before cursor.
+	MessagesBefore(ctx context.Context, sessionID int64, cursor time.Time, limit int) ([]Message, error)
+	// MessagesAfter returns messages from sessionID's active branch created
+	// strictly after cursor.
+	MessagesAfter(ctx context.Context, sessionID int64, cursor time.Time, limit int) ([]Message, error)
+
+	// BranchFromMessage forks the conversation at msgID into a new sibling
+	// holding newContent, and returns the new message's id.
+	BranchFromMessage(ctx context.Context, msgID int64, newContent string) (int64, error)
+	// SwitchBranch makes messageID the active leaf of sessionID.
+	SwitchBranch(ctx context.Context, sessionID, messageID int64) error
+	// ListChildren returns the direct children of messageID.
+	ListChildren(ctx context.Context, messageID int64) ([]Message, error)
+	// LoadPath returns the messages from leafID's trunk root to leafID.
+	LoadPath(ctx context.Context, leafID int64) ([]Message, error)
+
+	// ExportSession writes a session's active transcript in the given format.
+	ExportSession(ctx context.Context, id int64, format ExportFormat, w io.Writer) error
+	// ImportSession reads a transcript in the given format and creates a new session for it.
+	ImportSession(ctx context.Context, format ExportFormat, r io.Reader) (int64, error)
+	// ExportAll streams every session as one NDJSON line per session.
+	ExportAll(ctx context.Context, w io.Writer) error
+	// CloneSession duplicates a session and its full message tree under newName.
+	CloneSession(ctx context.Context, id int64, newName string) (int64, error)
+	// ExportSessionTree returns a full-fidelity snapshot of a session's
+	// entire message tree (every branch, not just the active path), for
+	// cross-backend migration.
+	ExportSessionTree(ctx context.Context, id int64) (*SessionTree, error)
+	// ImportSessionTree recreates a session from a snapshot produced by
+	// ExportSessionTree, preserving its branch structure, and returns the
+	// new session id.
+	ImportSessionTree(ctx context.Context, tree *SessionTree) (int64, error)
+
+	// PruneOlderThan deletes every session whose expiry is at or before
+	// cutoff, along with its messages, and reports how many of each were removed.
+	PruneOlderThan(ctx context.Context, cutoff time.Time) (deletedSessions, deletedMessages int, err error)
+	// PruneBySessionCount deletes the oldest sessions beyond keepMostRecent,
+	// along with their messages, and reports how many sessions were removed.
+	PruneBySessionCount(ctx context.Context, keepMostRecent int) (int, error)
+	// SetSessionTTL sets sessionID's expiry to ttl from now, or clears it
+	// when ttl is zero or negative.
+	SetSessionTTL(ctx context.Context, sessionID int64, ttl time.Duration) error
+
+	// Close releases any underlying resources.
+	Close() error
+}
+
+// Open dispatches to a storage backend based on the scheme of uri:
+//
+//	sqlite:/path/to/file.db   (or a bare path, or "" for the default location)
+//	postgres://...            (standard Postgres connection URI)
+//	memory:                   (in-memory backend, data does not survive Close)
+//
+// opts configures optional default TTLs, session caps, and background
+// retention sweeps shared across backends; see Options.
+func Open(uri string, opts ...Options) (Store, error) {
+	scheme, rest := splitDriverURI(uri)
+
+	var store Store
+	var err error
+	switch scheme {
+	case "", "sqlite":
+		store, err = OpenSQLite(rest, opts...)
+	case "postgres", "postgresql":
+		store, err = OpenPostgres(uri, opts...)
+	case "memory":
+		store, err = OpenMemory(opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return startRetention(store, mergeOptions(opts)), nil
+}
+
+// splitDriverURI separates a driver-URI's scheme from the remainder. A bare
+// path or an empty string has no scheme, so the SQLite backend can still
+// resolve it to the default on-disk location.
+func splitDriverURI(uri string) (scheme, rest string) {
+	trimmed := strings.TrimSpace(uri)
+	idx := strings.Index(trimmed, ":")
+	if idx < 0 {
+		return "", trimmed
+	}
+
+	scheme = trimmed[:idx]
+	switch scheme {
+	case "postgres", "postgresql":
+		// Postgres connection URIs keep their "://" body intact.
+		return scheme, trimmed
+	case "sqlite", "memory":
+		return scheme, strings.TrimPrefix(trimmed[idx+1:], "//")
+	default:
+		// No recognised scheme; treat the whole string as a filesystem path
+		// (Windows drive letters like "C:\" also land here).
+		return "", trimmed
+	}
+}